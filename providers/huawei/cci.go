@@ -2,14 +2,16 @@ package huawei
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/virtual-kubelet/virtual-kubelet/manager"
 	"github.com/virtual-kubelet/virtual-kubelet/providers/huawei/auth"
@@ -40,6 +42,18 @@ type CCIProvider struct {
 	cpu                string
 	memory             string
 	pods               string
+	podCache           *podCache
+	resyncInterval     time.Duration
+	health             *nodeHealth
+	healthPollInterval time.Duration
+	caBundlePath       string
+	authMode           string
+	iamEndpoint        string
+	iamDomainName      string
+	iamUserName        string
+	iamPassword        string
+	iamProjectID       string
+	iamTokens          iamTokenCache
 }
 
 // Client represents the client config for Huawei.
@@ -75,17 +89,39 @@ func NewCCIProvider(config string, rm *manager.ResourceManager, nodeName, operat
 	if p.appSecret == "" {
 		return nil, errors.New("AppSecret can not be empty please set CCI_APP_SECRET")
 	}
-	p.client.Signer = &auth.Signer{
-		AppKey:    p.appKey,
-		AppSecret: p.appSecret,
-		Region:    p.region,
-		Service:   p.service,
+	if caBundlePath := os.Getenv("CCI_CA_BUNDLE"); caBundlePath != "" {
+		p.caBundlePath = caBundlePath
 	}
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	client, err := newCCIClient(p.appKey, p.appSecret, p.region, p.service, p.caBundlePath)
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	if authMode := os.Getenv("CCI_AUTH_MODE"); authMode != "" {
+		p.authMode = authMode
 	}
-	p.client.HTTPClient = &http.Client{
-		Transport: tr,
+	if p.authMode == "" {
+		p.authMode = authModeSignature
+	}
+	if p.authMode == authModeIAM {
+		if iamEndpoint := os.Getenv("CCI_IAM_ENDPOINT"); iamEndpoint != "" {
+			p.iamEndpoint = iamEndpoint
+		}
+		if iamDomainName := os.Getenv("CCI_IAM_DOMAIN_NAME"); iamDomainName != "" {
+			p.iamDomainName = iamDomainName
+		}
+		if iamUserName := os.Getenv("CCI_IAM_USERNAME"); iamUserName != "" {
+			p.iamUserName = iamUserName
+		}
+		if iamPassword := os.Getenv("CCI_IAM_PASSWORD"); iamPassword != "" {
+			p.iamPassword = iamPassword
+		}
+		if iamProjectID := os.Getenv("CCI_IAM_PROJECT_ID"); iamProjectID != "" {
+			p.iamProjectID = iamProjectID
+		}
+		if p.iamEndpoint == "" || p.iamDomainName == "" || p.iamUserName == "" || p.iamPassword == "" || p.iamProjectID == "" {
+			return nil, errors.New("CCI_AUTH_MODE=iam requires CCI_IAM_ENDPOINT, CCI_IAM_DOMAIN_NAME, CCI_IAM_USERNAME, CCI_IAM_PASSWORD and CCI_IAM_PROJECT_ID to be set")
+		}
 	}
 	p.resourceManager = rm
 	p.apiEndpoint = defaultApiEndpoint
@@ -93,10 +129,18 @@ func NewCCIProvider(config string, rm *manager.ResourceManager, nodeName, operat
 	p.operatingSystem = operatingSystem
 	p.internalIP = internalIP
 	p.daemonEndpointPort = daemonEndpointPort
+	p.podCache = newPodCache()
+	p.resyncInterval = defaultResyncInterval
+	p.health = newNodeHealth()
+	p.healthPollInterval = defaultHealthPollInterval
 
 	if err := p.createProject(); err != nil {
 		return nil, err
 	}
+
+	go p.pollHealth(context.Background())
+	go p.Run(context.Background())
+
 	return &p, nil
 }
 
@@ -126,15 +170,23 @@ func (p *CCIProvider) createProject() error {
 	if err != nil {
 		return err
 	}
-	if err = p.signRequest(r); err != nil {
-		return fmt.Errorf("Sign the request failed: %v", err)
-	}
-	_, err = p.client.HTTPClient.Do(r)
-	return err
+	return p.do(r, nil)
 }
 
+// signRequest authenticates r for CCI, either by AK/SK request signing (the default) or,
+// when authMode is "iam", by attaching a Huawei IAM X-Subject-Token.
 func (p *CCIProvider) signRequest(r *http.Request) error {
-	r.Header.Add("content-type", "application/json; charset=utf-8")
+	r.Header.Set("content-type", "application/json; charset=utf-8")
+
+	if p.authMode == authModeIAM {
+		token, err := p.iamToken(r.Context())
+		if err != nil {
+			return fmt.Errorf("get IAM token failed: %v", err)
+		}
+		r.Header.Set("X-Auth-Token", token)
+		return nil
+	}
+
 	if err := p.client.Signer.Sign(r); err != nil {
 		return fmt.Errorf("Sign the request failed: %v", err)
 	}
@@ -142,8 +194,8 @@ func (p *CCIProvider) signRequest(r *http.Request) error {
 }
 
 func (p *CCIProvider) setPodAnnotations(pod *v1.Pod) {
-	pod.Namespace = p.project
 	metav1.SetMetaDataAnnotation(&pod.ObjectMeta, podAnnotationNamespaceKey, pod.Namespace)
+	pod.Namespace = p.project
 }
 
 func (p *CCIProvider) deletePodAnnotations(pod *v1.Pod) {
@@ -170,12 +222,7 @@ func (p *CCIProvider) CreatePod(pod *v1.Pod) error {
 	if err != nil {
 		return err
 	}
-
-	if err = p.signRequest(r); err != nil {
-		return fmt.Errorf("Sign the request failed: %v", err)
-	}
-	_, err = p.client.HTTPClient.Do(r)
-	return err
+	return mapCCIError(p.do(r, nil), podsGroupResource, pod.Name)
 }
 
 // UpdatePod takes a Kubernetes Pod and updates it within the huawei CCI provider.
@@ -197,12 +244,7 @@ func (p *CCIProvider) UpdatePod(pod *v1.Pod) error {
 	if err != nil {
 		return err
 	}
-
-	if err = p.signRequest(r); err != nil {
-		return fmt.Errorf("Sign the request failed: %v", err)
-	}
-	_, err = p.client.HTTPClient.Do(r)
-	return err
+	return mapCCIError(p.do(r, nil), podsGroupResource, pod.Name)
 }
 
 // DeletePod takes a Kubernetes Pod and deletes it from the huawei CCI provider.
@@ -214,49 +256,44 @@ func (p *CCIProvider) DeletePod(pod *v1.Pod) error {
 	if err != nil {
 		return err
 	}
-
-	if err = p.signRequest(r); err != nil {
-		return fmt.Errorf("Sign the request failed: %v", err)
-	}
-	_, err = p.client.HTTPClient.Do(r)
-	return err
+	return mapCCIError(p.do(r, nil), podsGroupResource, pod.Name)
 }
 
-// GetPod retrieves a pod by name from the huawei CCI provider.
+// GetPod retrieves a pod by name from the huawei CCI provider's watch-backed pod cache.
 func (p *CCIProvider) GetPod(namespace, name string) (*v1.Pod, error) {
-	// Create the getPod request url
-	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods/" + name
-	r, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, fmt.Errorf("Create get POD request failed: %v", err)
+	pod, ok := p.podCache.get(namespace, name)
+	if !ok {
+		return nil, nil
 	}
+	return pod, nil
+}
 
-	if err = p.signRequest(r); err != nil {
-		return nil, fmt.Errorf("Sign the request failed: %v", err)
-	}
+// GetContainerLogs retrieves the logs of a container by name from the huawei CCI provider.
+func (p *CCIProvider) GetContainerLogs(namespace, podName, containerName string, tail int) (string, error) {
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods/" + podName + "/log"
 
-	resp, err := p.client.HTTPClient.Do(r)
+	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("parse get logs url failed: %v", err)
 	}
+	q := u.Query()
+	q.Set("container", containerName)
+	q.Set("follow", "false")
+	if tail > 0 {
+		q.Set("tailLines", strconv.Itoa(tail))
+	}
+	u.RawQuery = q.Encode()
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	r, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("create get logs request failed: %v", err)
 	}
 
-	var pod v1.Pod
-	if err = json.Unmarshal(body, &pod); err != nil {
-		return nil, err
+	body, err := p.doRaw(r)
+	if err != nil {
+		return "", mapCCIError(err, podsGroupResource, podName)
 	}
-	p.deletePodAnnotations(&pod)
-	return &pod, nil
-}
-
-// GetContainerLogs retrieves the logs of a container by name from the huawei CCI provider.
-func (p *CCIProvider) GetContainerLogs(namespace, podName, containerName string, tail int) (string, error) {
-	return "", nil
+	return string(body), nil
 }
 
 // GetPodStatus retrieves the status of a pod by name from the huawei CCI provider.
@@ -273,41 +310,19 @@ func (p *CCIProvider) GetPodStatus(namespace, name string) (*v1.PodStatus, error
 	return &pod.Status, nil
 }
 
-// GetPods retrieves a list of all pods running on the huawei CCI provider.
+// GetPods retrieves a list of all pods running on the huawei CCI provider from the
+// watch-backed pod cache.
 func (p *CCIProvider) GetPods() ([]*v1.Pod, error) {
-	// Create the getPod request url
-	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods"
-	r, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, fmt.Errorf("Create get POD request failed: %v", err)
-	}
-
-	if err = p.signRequest(r); err != nil {
-		return nil, fmt.Errorf("Sign the request failed: %v", err)
-	}
-	resp, err := p.client.HTTPClient.Do(r)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var pods []*v1.Pod
-	if err = json.Unmarshal(body, &pods); err != nil {
-		return nil, err
-	}
-	for _, pod := range pods {
-		p.deletePodAnnotations(pod)
-	}
-	return pods, nil
+	return p.podCache.list(), nil
 }
 
-// Capacity returns a resource list with the capacity constraints of the huawei CCI provider.
+// Capacity returns a resource list with the capacity constraints of the huawei CCI
+// provider, backed by the health poller's last successful quota read. Until the first
+// poll completes, it falls back to the static values loaded from config.
 func (p *CCIProvider) Capacity() v1.ResourceList {
+	if capacityList, ok := p.health.capacity(); ok {
+		return capacityList
+	}
 	return v1.ResourceList{
 		"cpu":    resource.MustParse(p.cpu),
 		"memory": resource.MustParse(p.memory),
@@ -315,52 +330,12 @@ func (p *CCIProvider) Capacity() v1.ResourceList {
 	}
 }
 
-// NodeConditions returns a list of conditions (Ready, OutOfDisk, etc), which is
-// polled periodically to update the node status within Kubernetes.
+// NodeConditions returns the Ready, MemoryPressure, DiskPressure and NetworkUnavailable
+// conditions. The pressure conditions come from the health poller's last successful quota
+// read against CCI; Ready and NetworkUnavailable come from the last request recorded by
+// doRaw.
 func (p *CCIProvider) NodeConditions() []v1.NodeCondition {
-	// TODO: Make these dynamic and augment with custom CCI specific conditions of interest
-	return []v1.NodeCondition{
-		{
-			Type:               "Ready",
-			Status:             v1.ConditionTrue,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletReady",
-			Message:            "kubelet is ready.",
-		},
-		{
-			Type:               "OutOfDisk",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasSufficientDisk",
-			Message:            "kubelet has sufficient disk space available",
-		},
-		{
-			Type:               "MemoryPressure",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasSufficientMemory",
-			Message:            "kubelet has sufficient memory available",
-		},
-		{
-			Type:               "DiskPressure",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasNoDiskPressure",
-			Message:            "kubelet has no disk pressure",
-		},
-		{
-			Type:               "NetworkUnavailable",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "RouteCreated",
-			Message:            "RouteController created a route",
-		},
-	}
+	return p.health.conditions()
 }
 
 // NodeAddresses returns a list of addresses for the node status
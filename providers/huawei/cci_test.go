@@ -0,0 +1,140 @@
+package huawei
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/providers/huawei/auth"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestProvider(t *testing.T, srv *httptest.Server) *CCIProvider {
+	t.Helper()
+	return &CCIProvider{
+		project:     "test-project",
+		apiEndpoint: srv.URL,
+		client: &Client{
+			Signer:     &auth.Signer{AppKey: "ak", AppSecret: "sk"},
+			HTTPClient: srv.Client(),
+		},
+		podCache: newPodCache(),
+		health:   newNodeHealth(),
+	}
+}
+
+func TestGetContainerLogs(t *testing.T) {
+	const wantLogs = "hello from container\n"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("container"), "web"; got != want {
+			t.Errorf("container query = %q, want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("tailLines"), "10"; got != want {
+			t.Errorf("tailLines query = %q, want %q", got, want)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(wantLogs))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	logs, err := p.GetContainerLogs("default", "mypod", "web", 10)
+	if err != nil {
+		t.Fatalf("GetContainerLogs() error = %v", err)
+	}
+	if logs != wantLogs {
+		t.Errorf("GetContainerLogs() = %q, want %q", logs, wantLogs)
+	}
+}
+
+// TestNewCCIClientDoesNotPanic guards the bug newCCIClient was extracted to fix:
+// NewCCIProvider used to assign Signer onto a *Client before the *Client was ever
+// allocated, which panicked with a nil pointer dereference. This exercises that
+// construction path directly, without making a real network call or starting the
+// background goroutines NewCCIProvider launches against the live CCI endpoint.
+func TestNewCCIClientDoesNotPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("newCCIClient() panicked: %v", r)
+		}
+	}()
+
+	client, err := newCCIClient("ak", "sk", "region", "service", "")
+	if err != nil {
+		t.Fatalf("newCCIClient() error = %v", err)
+	}
+	if client.Signer == nil {
+		t.Fatal("newCCIClient() Signer = nil")
+	}
+	if client.HTTPClient == nil {
+		t.Fatal("newCCIClient() HTTPClient = nil")
+	}
+}
+
+// TestCreatePodRoundTripsRealNamespace drives CreatePod -> listPods -> GetPod end to end
+// with a real namespace different from p.project, guarding against setPodAnnotations
+// capturing p.project into the virtual-kubelet-namespace annotation instead of the pod's
+// actual namespace (which would make every real pod unreachable via GetPod once cached).
+func TestCreatePodRoundTripsRealNamespace(t *testing.T) {
+	const realNamespace = "default"
+
+	var stored []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored = body
+			w.Header().Set("content-type", "application/json")
+			w.Write(body)
+		case http.MethodGet:
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, stored)
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mypod", Namespace: realNamespace}}
+	if err := p.CreatePod(pod); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+
+	if err := p.listPods(); err != nil {
+		t.Fatalf("listPods() error = %v", err)
+	}
+
+	got, err := p.GetPod(realNamespace, "mypod")
+	if err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetPod() = nil, want the pod cached under its real namespace")
+	}
+	if got.Namespace != realNamespace {
+		t.Errorf("GetPod().Namespace = %q, want %q", got.Namespace, realNamespace)
+	}
+}
+
+func TestGetContainerLogsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("pod not found"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	if _, err := p.GetContainerLogs("default", "mypod", "web", 0); err == nil {
+		t.Fatal("GetContainerLogs() error = nil, want non-nil on 404")
+	}
+}
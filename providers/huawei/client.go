@@ -0,0 +1,264 @@
+package huawei
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/virtual-kubelet/virtual-kubelet/providers/huawei/auth"
+)
+
+// Auth modes selectable via the CCI_AUTH_MODE environment variable (the config file has
+// no auth mode setting): "signature" (the default AK/SK request signing) or "iam", which
+// obtains a Huawei IAM token with the IAM user's name and password (the AK/SK are not
+// used for it) and sends it as X-Auth-Token instead of signing every request.
+const (
+	authModeSignature = "signature"
+	authModeIAM       = "iam"
+)
+
+// podsGroupResource identifies the pods resource for mapCCIError, matching the GroupResource
+// the rest of the virtual-kubelet core uses when checking errors with apierrors.IsNotFound etc.
+var podsGroupResource = schema.GroupResource{Resource: "pods"}
+
+// CCIError represents the error envelope CCI returns on a non-2xx response.
+type CCIError struct {
+	StatusCode int
+	Code       string `json:"error_code"`
+	Message    string `json:"error_msg"`
+	RequestID  string `json:"request_id"`
+}
+
+func (e *CCIError) Error() string {
+	return fmt.Sprintf("cci: request %s failed with status %d: %s (code=%s)", e.RequestID, e.StatusCode, e.Message, e.Code)
+}
+
+func newCCIError(statusCode int, body []byte) *CCIError {
+	cciErr := &CCIError{StatusCode: statusCode}
+	if err := json.Unmarshal(body, cciErr); err != nil || cciErr.Message == "" {
+		cciErr.Message = string(body)
+	}
+	cciErr.StatusCode = statusCode
+	return cciErr
+}
+
+// mapCCIError translates a CCIError returned by do into the equivalent
+// k8s.io/apimachinery/pkg/api/errors type, so callers (and the virtual-kubelet core
+// that inspects them with apierrors.IsNotFound/IsConflict) see the errors they expect.
+// Errors that aren't CCIErrors, or that don't have a well-known mapping, are returned
+// unchanged.
+func mapCCIError(err error, gr schema.GroupResource, name string) error {
+	cciErr, ok := err.(*CCIError)
+	if !ok {
+		return err
+	}
+	switch cciErr.StatusCode {
+	case http.StatusNotFound:
+		return apierrors.NewNotFound(gr, name)
+	case http.StatusConflict:
+		return apierrors.NewConflict(gr, name, cciErr)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return apierrors.NewUnauthorized(cciErr.Message)
+	default:
+		return cciErr
+	}
+}
+
+// do sends req with the provider's configured authentication, reads and checks the
+// response, and on success decodes the body into out (if out is non-nil and the body
+// is non-empty). Non-2xx responses are returned as a *CCIError.
+func (p *CCIProvider) do(req *http.Request, out interface{}) error {
+	body, err := p.doRaw(req)
+	if err != nil {
+		return err
+	}
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doRaw sends req with the provider's configured authentication and returns the raw
+// response body on success. Non-2xx responses are returned as a *CCIError. Every request
+// to CCI passes through here except the pod watch stream (watchPods), the exec upgrade
+// (ExecInContainer) and the IAM token request (fetchIAMToken); the outcome of requests
+// made here is recorded on p.health for the Ready/NetworkUnavailable node conditions,
+// while the outcome of those three is not.
+func (p *CCIProvider) doRaw(req *http.Request) ([]byte, error) {
+	body, err := p.doRawUnrecorded(req)
+	p.health.recordRequestResult(err)
+	return body, err
+}
+
+func (p *CCIProvider) doRawUnrecorded(req *http.Request) ([]byte, error) {
+	if err := p.signRequest(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newCCIError(resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// newCCIClient builds the Client used to sign and send every request to CCI. It exists
+// mainly so NewCCIProvider can't repeat the bug this replaced: Signer used to be assigned
+// onto a *Client before the *Client was ever allocated, which panicked with a nil pointer
+// dereference.
+func newCCIClient(appKey, appSecret, region, service, caBundlePath string) (*Client, error) {
+	tlsConfig, err := newTLSConfig(caBundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Signer: &auth.Signer{
+			AppKey:    appKey,
+			AppSecret: appSecret,
+			Region:    region,
+			Service:   service,
+		},
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// newTLSConfig builds the TLS config used for every connection to CCI. If caBundlePath
+// is set, it is loaded as the pool of trusted roots; otherwise the host's default root
+// set is used. CCI connections are never made with certificate verification disabled.
+func newTLSConfig(caBundlePath string) (*tls.Config, error) {
+	if caBundlePath == "" {
+		return &tls.Config{}, nil
+	}
+
+	pem, err := ioutil.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle %q: %v", caBundlePath, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %q", caBundlePath)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// iamTokenResponse is the subset of Huawei IAM's token create response this provider
+// needs: the expiry, used to know when to refresh.
+type iamTokenResponse struct {
+	Token struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	} `json:"token"`
+}
+
+// iamTokenCache caches the last IAM token obtained by fetchIAMToken, so it is only
+// refreshed once it is close to expiring.
+type iamTokenCache struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// iamToken returns a valid IAM X-Subject-Token, fetching a new one if the cached token is
+// missing or within 5 minutes of expiring.
+func (p *CCIProvider) iamToken(ctx context.Context) (string, error) {
+	p.iamTokens.mu.Lock()
+	defer p.iamTokens.mu.Unlock()
+
+	if p.iamTokens.token != "" && time.Now().Add(5*time.Minute).Before(p.iamTokens.expiresAt) {
+		return p.iamTokens.token, nil
+	}
+
+	token, expiresAt, err := p.fetchIAMToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.iamTokens.token = token
+	p.iamTokens.expiresAt = expiresAt
+	return token, nil
+}
+
+// fetchIAMToken obtains a Huawei IAM X-Subject-Token via the IAM password grant
+// (POST /v3/auth/tokens) with the configured IAM user name, password, domain and project
+// ID; the provider's AK/SK are not used. It returns the token from the X-Subject-Token
+// response header and its expiry from the response body.
+func (p *CCIProvider) fetchIAMToken(ctx context.Context) (string, time.Time, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"name":     p.iamUserName,
+						"password": p.iamPassword,
+						"domain": map[string]interface{}{
+							"name": p.iamDomainName,
+						},
+					},
+				},
+			},
+			"scope": map[string]interface{}{
+				"project": map[string]interface{}{
+					"id": p.iamProjectID,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req, err := http.NewRequest("POST", p.iamEndpoint+"/v3/auth/tokens", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("content-type", "application/json; charset=utf-8")
+
+	resp, err := p.client.HTTPClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", time.Time{}, newCCIError(resp.StatusCode, body)
+	}
+
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("IAM token response missing X-Subject-Token header")
+	}
+
+	var tokenResp iamTokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, tokenResp.Token.ExpiresAt, nil
+}
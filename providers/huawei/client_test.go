@@ -0,0 +1,113 @@
+package huawei
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestDoMapsErrorResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		check      func(t *testing.T, err error)
+	}{
+		{
+			name:       "not found",
+			statusCode: http.StatusNotFound,
+			body:       `{"error_code": "CCI.0404", "error_msg": "pod not found", "request_id": "req-1"}`,
+			check: func(t *testing.T, err error) {
+				if !apierrors.IsNotFound(mapCCIError(err, podsGroupResource, "mypod")) {
+					t.Errorf("mapCCIError(%v) is not a NotFound error", err)
+				}
+			},
+		},
+		{
+			name:       "conflict",
+			statusCode: http.StatusConflict,
+			body:       `{"error_code": "CCI.0409", "error_msg": "resource version conflict", "request_id": "req-2"}`,
+			check: func(t *testing.T, err error) {
+				if !apierrors.IsConflict(mapCCIError(err, podsGroupResource, "mypod")) {
+					t.Errorf("mapCCIError(%v) is not a Conflict error", err)
+				}
+			},
+		},
+		{
+			name:       "unauthorized",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error_code": "CCI.0401", "error_msg": "invalid signature", "request_id": "req-3"}`,
+			check: func(t *testing.T, err error) {
+				if !apierrors.IsUnauthorized(mapCCIError(err, podsGroupResource, "mypod")) {
+					t.Errorf("mapCCIError(%v) is not an Unauthorized error", err)
+				}
+			},
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error_code": "CCI.0500", "error_msg": "internal error", "request_id": "req-4"}`,
+			check: func(t *testing.T, err error) {
+				mapped := mapCCIError(err, podsGroupResource, "mypod")
+				cciErr, ok := mapped.(*CCIError)
+				if !ok {
+					t.Fatalf("mapCCIError(%v) = %T, want *CCIError", err, mapped)
+				}
+				if cciErr.RequestID != "req-4" {
+					t.Errorf("RequestID = %q, want %q", cciErr.RequestID, "req-4")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := newTestProvider(t, srv)
+			r, err := http.NewRequest("GET", srv.URL+"/api/v1/namespaces/test-project/pods/mypod", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.do(r, nil)
+			if err == nil {
+				t.Fatal("do() error = nil, want non-nil")
+			}
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestDoSuccessDecodesBody(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"metadata": {"name": "mypod", "resourceVersion": "42"}}`))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	r, err := http.NewRequest("GET", srv.URL+"/api/v1/namespaces/test-project/pods/mypod", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Metadata struct {
+			Name            string `json:"name"`
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err := p.do(r, &out); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+	if out.Metadata.ResourceVersion != "42" {
+		t.Errorf("resourceVersion = %q, want %q", out.Metadata.ResourceVersion, "42")
+	}
+}
@@ -0,0 +1,120 @@
+package huawei
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// signingRoundTripper wraps a http.RoundTripper and signs every outgoing
+// request the same way the rest of the CCI client does, so the SPDY upgrade
+// request carries valid credentials.
+type signingRoundTripper struct {
+	provider *CCIProvider
+	delegate http.RoundTripper
+}
+
+func (s *signingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := s.provider.signRequest(req); err != nil {
+		return nil, fmt.Errorf("sign the exec request failed: %v", err)
+	}
+	return s.delegate.RoundTrip(req)
+}
+
+// execURL builds the CCI exec subresource URL for container, including the query
+// parameters that select which streams are attached and what command to run.
+func (p *CCIProvider) execURL(name, container string, cmd []string, hasIn, hasOut, hasErr, tty bool) (*url.URL, error) {
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods/" + name + "/exec"
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse exec url failed: %v", err)
+	}
+	q := u.Query()
+	q.Set("container", container)
+	q.Set("stdin", strconv.FormatBool(hasIn))
+	q.Set("stdout", strconv.FormatBool(hasOut))
+	q.Set("stderr", strconv.FormatBool(!tty && hasErr))
+	q.Set("tty", strconv.FormatBool(tty))
+	for _, c := range cmd {
+		q.Add("command", c)
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// terminalSizeQueue adapts a resize channel, as handed to ExecInContainer by the
+// virtual-kubelet api server, to remotecommand's TerminalSizeQueue interface.
+type terminalSizeQueue struct {
+	resize <-chan remotecommand.TerminalSize
+}
+
+func (t terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-t.resize
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+// ExecInContainer executes a command in a container of a pod running on the huawei CCI
+// provider, upgrading the connection to SPDY and streaming stdin/stdout/stderr through
+// the pod's exec subresource. It returns once the remote command completes, timeout
+// elapses (if non-zero), or the upgrade/stream setup fails. uid is accepted to satisfy
+// the provider interface but is not used to address the pod, since CCI pods are looked
+// up by name alone.
+func (p *CCIProvider) ExecInContainer(name string, uid types.UID, container string, cmd []string, in io.Reader, out, errOut io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize, timeout time.Duration) error {
+	u, err := p.execURL(name, container, cmd, in != nil, out != nil, errOut != nil, tty)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := p.client.HTTPClient.Transport.(*http.Transport).TLSClientConfig
+	upgradeRoundTripper := spdy.NewRoundTripper(tlsConfig)
+	transport := &signingRoundTripper{provider: p, delegate: upgradeRoundTripper}
+
+	exec, err := remotecommand.NewSPDYExecutorForTransports(transport, upgradeRoundTripper, "POST", u)
+	if err != nil {
+		return fmt.Errorf("create spdy executor failed: %v", err)
+	}
+
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  in,
+		Stdout: out,
+		Stderr: errOut,
+		Tty:    tty,
+	}
+	if resize != nil {
+		streamOptions.TerminalSizeQueue = terminalSizeQueue{resize: resize}
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	streamCh := make(chan error, 1)
+	go func() {
+		streamCh <- exec.Stream(streamOptions)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// exec.Stream has no context of its own, so the only way to unblock the
+		// goroutine above is to tear down the connection it's reading/writing on.
+		upgradeRoundTripper.Close()
+		return ctx.Err()
+	case err := <-streamCh:
+		return err
+	}
+}
@@ -0,0 +1,92 @@
+package huawei
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExecURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	u, err := p.execURL("mypod", "web", []string{"sh", "-c", "echo hi"}, true, true, true, false)
+	if err != nil {
+		t.Fatalf("execURL() error = %v", err)
+	}
+
+	if got, want := u.Path, "/api/v1/namespaces/test-project/pods/mypod/exec"; got != want {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+
+	q := u.Query()
+	if got, want := q.Get("container"), "web"; got != want {
+		t.Errorf("container query = %q, want %q", got, want)
+	}
+	for _, tc := range []struct {
+		key  string
+		want string
+	}{
+		{"stdin", "true"},
+		{"stdout", "true"},
+		{"stderr", "true"},
+		{"tty", "false"},
+	} {
+		if got := q.Get(tc.key); got != tc.want {
+			t.Errorf("%s query = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+	if got, want := fmt.Sprint(q["command"]), fmt.Sprint([]string{"sh", "-c", "echo hi"}); got != want {
+		t.Errorf("command query = %v, want %v", q["command"], []string{"sh", "-c", "echo hi"})
+	}
+}
+
+func TestExecURLStderrSuppressedWithTty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	u, err := p.execURL("mypod", "web", nil, true, true, true, true)
+	if err != nil {
+		t.Fatalf("execURL() error = %v", err)
+	}
+	if got, want := u.Query().Get("stderr"), "false"; got != want {
+		t.Errorf("stderr query with tty = %q, want %q", got, want)
+	}
+}
+
+// fakeRoundTripper records the last request it saw and returns a canned response.
+type fakeRoundTripper struct {
+	lastReq *http.Request
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.lastReq = req
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header)}, nil
+}
+
+func TestSigningRoundTripperSignsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	delegate := &fakeRoundTripper{}
+	transport := &signingRoundTripper{provider: p, delegate: delegate}
+
+	req, err := http.NewRequest("POST", srv.URL+"/api/v1/namespaces/test-project/pods/mypod/exec", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	if delegate.lastReq == nil {
+		t.Fatal("expected the delegate to receive the request")
+	}
+	if got := delegate.lastReq.Header.Get("content-type"); got == "" {
+		t.Error("expected signRequest to have set a content-type header before delegating")
+	}
+}
@@ -0,0 +1,251 @@
+package huawei
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultHealthPollInterval is how often the provider refreshes capacity and pressure
+// conditions from CCI's resourcequotas endpoint.
+const defaultHealthPollInterval = time.Minute
+
+// pressureThreshold is the fraction of a quota's hard limit above which the
+// corresponding pressure condition is reported true.
+const pressureThreshold = 0.9
+
+// nodeHealth caches the node's capacity, pressure and readiness state, so Capacity and
+// NodeConditions can be served without blocking on a CCI round trip. Capacity and
+// pressure are last computed by pollHealth's quota poll; readiness and network
+// availability instead reflect every request the provider makes to CCI through do/doRaw
+// in client.go (recorded via recordRequestResult), so a pod create or log fetch failing
+// surfaces the same way a failed quota poll would. The pod watch stream and the exec
+// upgrade do not go through doRaw and are not recorded.
+type nodeHealth struct {
+	mu           sync.RWMutex
+	capacityList v1.ResourceList
+	haveCapacity bool
+
+	memoryPressure bool
+	diskPressure   bool
+
+	lastRequestOK   bool
+	lastRequestErr  error
+	lastRequestTime metav1.Time
+}
+
+func newNodeHealth() *nodeHealth {
+	return &nodeHealth{}
+}
+
+// recordRequestResult records the outcome of the most recent signed request made to CCI
+// through do/doRaw, driving the Ready and NetworkUnavailable node conditions.
+func (h *nodeHealth) recordRequestResult(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRequestOK = err == nil
+	h.lastRequestErr = err
+	h.lastRequestTime = metav1.Now()
+}
+
+func (h *nodeHealth) capacity() (v1.ResourceList, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.capacityList, h.haveCapacity
+}
+
+func (h *nodeHealth) conditions() []v1.NodeCondition {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	readyStatus, readyReason, readyMessage := v1.ConditionFalse, "CCIRequestFailed", "last signed request to CCI failed"
+	if h.lastRequestOK {
+		readyStatus, readyReason, readyMessage = v1.ConditionTrue, "KubeletReady", "kubelet is ready."
+	}
+	if h.lastRequestErr != nil {
+		readyMessage = h.lastRequestErr.Error()
+	}
+
+	heartbeat := h.lastRequestTime
+	if heartbeat.IsZero() {
+		heartbeat = metav1.Now()
+	}
+
+	return []v1.NodeCondition{
+		{
+			Type:               v1.NodeReady,
+			Status:             readyStatus,
+			LastHeartbeatTime:  heartbeat,
+			LastTransitionTime: heartbeat,
+			Reason:             readyReason,
+			Message:            readyMessage,
+		},
+		{
+			Type:               v1.NodeMemoryPressure,
+			Status:             conditionBool(h.memoryPressure),
+			LastHeartbeatTime:  heartbeat,
+			LastTransitionTime: heartbeat,
+			Reason:             pressureReason(h.memoryPressure, "KubeletHasInsufficientMemory", "KubeletHasSufficientMemory"),
+			Message:            pressureMessage(h.memoryPressure, "memory", pressureThreshold),
+		},
+		{
+			Type:               v1.NodeDiskPressure,
+			Status:             conditionBool(h.diskPressure),
+			LastHeartbeatTime:  heartbeat,
+			LastTransitionTime: heartbeat,
+			Reason:             pressureReason(h.diskPressure, "KubeletHasDiskPressure", "KubeletHasNoDiskPressure"),
+			Message:            pressureMessage(h.diskPressure, "disk", pressureThreshold),
+		},
+		{
+			Type:               v1.NodeNetworkUnavailable,
+			Status:             conditionBool(!h.lastRequestOK),
+			LastHeartbeatTime:  heartbeat,
+			LastTransitionTime: heartbeat,
+			Reason:             pressureReason(!h.lastRequestOK, "NoRouteToCCI", "RouteCreated"),
+			Message:            networkMessage(!h.lastRequestOK, h.lastRequestErr),
+		},
+	}
+}
+
+func conditionBool(b bool) v1.ConditionStatus {
+	if b {
+		return v1.ConditionTrue
+	}
+	return v1.ConditionFalse
+}
+
+func pressureReason(pressure bool, trueReason, falseReason string) string {
+	if pressure {
+		return trueReason
+	}
+	return falseReason
+}
+
+func pressureMessage(pressure bool, resourceName string, threshold float64) string {
+	if pressure {
+		return fmt.Sprintf("%s usage is above %.0f%% of quota", resourceName, threshold*100)
+	}
+	return fmt.Sprintf("%s usage is within quota", resourceName)
+}
+
+func networkMessage(unavailable bool, lastErr error) string {
+	if !unavailable {
+		return "last signed request to CCI succeeded"
+	}
+	if lastErr != nil {
+		return fmt.Sprintf("last signed request to CCI failed: %v", lastErr)
+	}
+	return "last signed request to CCI failed"
+}
+
+// pollHealth runs until ctx is cancelled, periodically refreshing capacity and pressure
+// conditions from CCI's resourcequotas endpoint.
+func (p *CCIProvider) pollHealth(ctx context.Context) {
+	ticker := time.NewTicker(p.healthPollInterval)
+	defer ticker.Stop()
+
+	p.refreshHealth(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshHealth(ctx)
+		}
+	}
+}
+
+// refreshHealth refreshes capacity and pressure from CCI's quota endpoint. Readiness and
+// network availability are not set here: do/doRaw already records the outcome of this
+// same quota request (and every other request the provider makes through do/doRaw) via
+// nodeHealth.recordRequestResult, so Ready reflects the provider's last such request to
+// CCI of any kind, not just this poll.
+func (p *CCIProvider) refreshHealth(ctx context.Context) {
+	quotas, err := p.getResourceQuotas(ctx)
+	if err != nil {
+		log.Printf("huawei: health poll failed: %v", err)
+		return
+	}
+
+	capacityList, memPressure, diskPressure := computeCapacityAndPressure(quotas)
+
+	p.health.mu.Lock()
+	p.health.capacityList = capacityList
+	p.health.haveCapacity = true
+	p.health.memoryPressure = memPressure
+	p.health.diskPressure = diskPressure
+	p.health.mu.Unlock()
+}
+
+// getResourceQuotas lists the project's resource quotas from CCI.
+func (p *CCIProvider) getResourceQuotas(ctx context.Context) (*v1.ResourceQuotaList, error) {
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/resourcequotas"
+	r, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create get resourcequotas request failed: %v", err)
+	}
+	r = r.WithContext(ctx)
+
+	var quotas v1.ResourceQuotaList
+	if err := p.do(r, &quotas); err != nil {
+		return nil, err
+	}
+	return &quotas, nil
+}
+
+// computeCapacityAndPressure sums hard limits and usage across every quota in the
+// project, returning the remaining cpu/memory/pods capacity and whether memory and
+// ephemeral-storage usage are each above pressureThreshold of their hard limit.
+func computeCapacityAndPressure(quotas *v1.ResourceQuotaList) (v1.ResourceList, bool, bool) {
+	var hardCPU, hardMem, hardPods, hardStorage resource.Quantity
+	var usedCPU, usedMem, usedPods, usedStorage resource.Quantity
+
+	for _, q := range quotas.Items {
+		addQuantity(&hardCPU, q.Status.Hard, v1.ResourceCPU)
+		addQuantity(&hardMem, q.Status.Hard, v1.ResourceMemory)
+		addQuantity(&hardPods, q.Status.Hard, v1.ResourcePods)
+		addQuantity(&hardStorage, q.Status.Hard, v1.ResourceEphemeralStorage)
+		addQuantity(&usedCPU, q.Status.Used, v1.ResourceCPU)
+		addQuantity(&usedMem, q.Status.Used, v1.ResourceMemory)
+		addQuantity(&usedPods, q.Status.Used, v1.ResourcePods)
+		addQuantity(&usedStorage, q.Status.Used, v1.ResourceEphemeralStorage)
+	}
+
+	remainingCPU := hardCPU.DeepCopy()
+	remainingCPU.Sub(usedCPU)
+	remainingMem := hardMem.DeepCopy()
+	remainingMem.Sub(usedMem)
+	remainingPods := hardPods.DeepCopy()
+	remainingPods.Sub(usedPods)
+
+	capacityList := v1.ResourceList{
+		v1.ResourceCPU:    remainingCPU,
+		v1.ResourceMemory: remainingMem,
+		v1.ResourcePods:   remainingPods,
+	}
+
+	memPressure := usageRatio(usedMem, hardMem) > pressureThreshold
+	diskPressure := usageRatio(usedStorage, hardStorage) > pressureThreshold
+
+	return capacityList, memPressure, diskPressure
+}
+
+func addQuantity(total *resource.Quantity, list v1.ResourceList, name v1.ResourceName) {
+	if q, ok := list[name]; ok {
+		total.Add(q)
+	}
+}
+
+func usageRatio(used, hard resource.Quantity) float64 {
+	if hard.IsZero() {
+		return 0
+	}
+	return used.AsApproximateFloat64() / hard.AsApproximateFloat64()
+}
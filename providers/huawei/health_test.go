@@ -0,0 +1,88 @@
+package huawei
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestComputeCapacityAndPressure(t *testing.T) {
+	quotas := &v1.ResourceQuotaList{
+		Items: []v1.ResourceQuota{
+			{
+				Status: v1.ResourceQuotaStatus{
+					Hard: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("10"),
+						v1.ResourceMemory: resource.MustParse("10Gi"),
+						v1.ResourcePods:   resource.MustParse("100"),
+					},
+					Used: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("2"),
+						v1.ResourceMemory: resource.MustParse("9.5Gi"),
+						v1.ResourcePods:   resource.MustParse("10"),
+					},
+				},
+			},
+		},
+	}
+
+	capacityList, memPressure, _ := computeCapacityAndPressure(quotas)
+
+	if !memPressure {
+		t.Error("memPressure = false, want true for 95% memory usage")
+	}
+	if got := capacityList[v1.ResourceCPU]; got.Cmp(resource.MustParse("8")) != 0 {
+		t.Errorf("remaining cpu = %v, want 8", got.String())
+	}
+}
+
+// TestNodeConditionsReflectAnyFailedRequest makes sure Ready/NetworkUnavailable follow
+// the outcome of any signed request made through do/doRaw, not only the health poller's
+// own quota poll - e.g. a failing CreatePod/GetContainerLogs call should surface here too.
+func TestNodeConditionsReflectAnyFailedRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error_msg": "boom"}`))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	r, err := http.NewRequest("GET", srv.URL+"/api/v1/namespaces/test-project/pods/mypod", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.do(r, nil); err == nil {
+		t.Fatal("do() error = nil, want non-nil")
+	}
+
+	conditions := p.health.conditions()
+	for _, c := range conditions {
+		switch c.Type {
+		case v1.NodeReady:
+			if c.Status != v1.ConditionFalse {
+				t.Errorf("NodeReady status = %v, want False after a failed request", c.Status)
+			}
+		case v1.NodeNetworkUnavailable:
+			if c.Status != v1.ConditionTrue {
+				t.Errorf("NodeNetworkUnavailable status = %v, want True after a failed request", c.Status)
+			}
+		}
+	}
+}
+
+func TestCapacityFallsBackBeforeFirstPoll(t *testing.T) {
+	p := &CCIProvider{
+		cpu:    "4",
+		memory: "8Gi",
+		pods:   "50",
+		health: newNodeHealth(),
+	}
+
+	got := p.Capacity()
+	if got.Cpu().String() != "4" {
+		t.Errorf("Capacity()[cpu] = %v, want 4", got.Cpu())
+	}
+}
@@ -0,0 +1,262 @@
+package huawei
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ApplyResult reports the outcome of applying a single object decoded from a manifest
+// stream passed to ApplyManifest.
+type ApplyResult struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Action    string // "created" or "updated"
+	Err       error
+}
+
+// manifestResource describes how a Kind maps onto CCI's namespaced REST collections.
+type manifestResource struct {
+	kind       string
+	collection string
+	namespaced bool
+}
+
+// groupResource returns the GroupResource mapCCIError should use when translating a
+// CCIError encountered while applying an object of this kind.
+func (r manifestResource) groupResource() schema.GroupResource {
+	return schema.GroupResource{Resource: r.collection}
+}
+
+var manifestResources = []manifestResource{
+	{kind: "Pod", collection: "pods", namespaced: true},
+	{kind: "Service", collection: "services", namespaced: true},
+	{kind: "ConfigMap", collection: "configmaps", namespaced: true},
+	{kind: "Secret", collection: "secrets", namespaced: true},
+	{kind: "PersistentVolumeClaim", collection: "persistentvolumeclaims", namespaced: true},
+	{kind: "Namespace", collection: "namespaces", namespaced: false},
+}
+
+func manifestResourceForKind(kind string) (manifestResource, bool) {
+	for _, r := range manifestResources {
+		if r.kind == kind {
+			return r, true
+		}
+	}
+	return manifestResource{}, false
+}
+
+// ApplyManifest decodes a multi-document YAML or JSON manifest stream and creates or
+// updates each object (Pod, Service, ConfigMap, Secret, PersistentVolumeClaim, Namespace)
+// against the CCI API, in document order. If an object fails to apply, any objects this
+// call created earlier are deleted before the error is returned; objects it updated in
+// place keep their updated state.
+func (p *CCIProvider) ApplyManifest(ctx context.Context, r io.Reader) ([]ApplyResult, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	var results []ApplyResult
+	var created []ApplyResult
+
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			p.rollback(created)
+			return results, fmt.Errorf("decode manifest document: %v", err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(raw, &typeMeta); err != nil {
+			p.rollback(created)
+			return results, fmt.Errorf("decode object type meta: %v", err)
+		}
+
+		res, ok := manifestResourceForKind(typeMeta.Kind)
+		if !ok {
+			p.rollback(created)
+			return results, fmt.Errorf("unsupported manifest kind %q", typeMeta.Kind)
+		}
+
+		result, err := p.applyObject(ctx, res, raw)
+		result.Err = err
+		results = append(results, result)
+		if err != nil {
+			p.rollback(created)
+			return results, fmt.Errorf("apply %s %q: %v", result.Kind, result.Name, err)
+		}
+		if result.Action == "created" {
+			created = append(created, result)
+		}
+	}
+
+	return results, nil
+}
+
+// applyObject creates the object named in raw, or updates it in place (after fetching
+// its current resourceVersion) if it already exists.
+func (p *CCIProvider) applyObject(ctx context.Context, res manifestResource, raw json.RawMessage) (ApplyResult, error) {
+	if res.kind == "Pod" {
+		stamped, err := p.stampPodAnnotations(raw)
+		if err != nil {
+			return ApplyResult{Kind: res.kind}, fmt.Errorf("stamp pod annotations: %v", err)
+		}
+		raw = stamped
+	}
+
+	var meta metav1.ObjectMeta
+	if err := json.Unmarshal(raw, &struct {
+		Metadata *metav1.ObjectMeta `json:"metadata"`
+	}{Metadata: &meta}); err != nil {
+		return ApplyResult{Kind: res.kind}, fmt.Errorf("decode object metadata: %v", err)
+	}
+
+	result := ApplyResult{Kind: res.kind, Namespace: p.project, Name: meta.Name}
+
+	existingVersion, err := p.getResourceVersion(ctx, res, meta.Name)
+	if err != nil {
+		return result, err
+	}
+
+	if existingVersion == "" {
+		if err := p.createResource(ctx, res, raw); err != nil {
+			return result, err
+		}
+		result.Action = "created"
+		return result, nil
+	}
+
+	updated, err := withResourceVersion(raw, existingVersion)
+	if err != nil {
+		return result, err
+	}
+	if err := p.updateResource(ctx, res, meta.Name, updated); err != nil {
+		return result, err
+	}
+	result.Action = "updated"
+	return result, nil
+}
+
+// stampPodAnnotations applies the same virtual-kubelet-namespace bookkeeping CreatePod
+// and UpdatePod use: it records the pod's real namespace in an annotation and rewrites
+// pod.Namespace to the CCI project, since CCI itself only has one namespace per
+// provider. Without this, the watch cache can't recover the real namespace for a pod
+// deployed through ApplyManifest and GetPod/GetPodStatus can never find it.
+func (p *CCIProvider) stampPodAnnotations(raw json.RawMessage) (json.RawMessage, error) {
+	var pod v1.Pod
+	if err := json.Unmarshal(raw, &pod); err != nil {
+		return nil, err
+	}
+	p.setPodAnnotations(&pod)
+	return json.Marshal(&pod)
+}
+
+func withResourceVersion(raw json.RawMessage, resourceVersion string) (json.RawMessage, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = resourceVersion
+	return json.Marshal(obj)
+}
+
+func (p *CCIProvider) resourceURI(res manifestResource, name string) string {
+	if !res.namespaced {
+		uri := p.apiEndpoint + "/api/v1/" + res.collection
+		if name != "" {
+			uri += "/" + name
+		}
+		return uri
+	}
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/" + res.collection
+	if name != "" {
+		uri += "/" + name
+	}
+	return uri
+}
+
+// getResourceVersion returns the resourceVersion of the named object, or "" if it does
+// not exist yet.
+func (p *CCIProvider) getResourceVersion(ctx context.Context, res manifestResource, name string) (string, error) {
+	r, err := http.NewRequest("GET", p.resourceURI(res, name), nil)
+	if err != nil {
+		return "", fmt.Errorf("create get %s request failed: %v", res.kind, err)
+	}
+	r = r.WithContext(ctx)
+
+	var meta struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := p.do(r, &meta); err != nil {
+		if cciErr, ok := err.(*CCIError); ok && cciErr.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", mapCCIError(err, res.groupResource(), name)
+	}
+	return meta.Metadata.ResourceVersion, nil
+}
+
+func (p *CCIProvider) createResource(ctx context.Context, res manifestResource, body json.RawMessage) error {
+	return p.sendResource(ctx, "POST", p.resourceURI(res, ""), res, "", body)
+}
+
+func (p *CCIProvider) updateResource(ctx context.Context, res manifestResource, name string, body json.RawMessage) error {
+	return p.sendResource(ctx, "PUT", p.resourceURI(res, name), res, name, body)
+}
+
+func (p *CCIProvider) deleteResource(ctx context.Context, res manifestResource, name string) error {
+	r, err := http.NewRequest("DELETE", p.resourceURI(res, name), nil)
+	if err != nil {
+		return fmt.Errorf("create delete %s request failed: %v", res.kind, err)
+	}
+	r = r.WithContext(ctx)
+
+	err = p.do(r, nil)
+	if cciErr, ok := err.(*CCIError); ok && cciErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return mapCCIError(err, res.groupResource(), name)
+}
+
+func (p *CCIProvider) sendResource(ctx context.Context, method, uri string, res manifestResource, name string, body json.RawMessage) error {
+	r, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create %s %s request failed: %v", method, res.kind, err)
+	}
+	r = r.WithContext(ctx)
+	return mapCCIError(p.do(r, nil), res.groupResource(), name)
+}
+
+// rollback deletes every resource this ApplyManifest call created, in reverse order, so
+// a failed manifest does not leave a partial deployment behind. Rollback errors are
+// logged rather than returned since the original apply error takes precedence.
+func (p *CCIProvider) rollback(created []ApplyResult) {
+	for i := len(created) - 1; i >= 0; i-- {
+		res, ok := manifestResourceForKind(created[i].Kind)
+		if !ok {
+			continue
+		}
+		if err := p.deleteResource(context.Background(), res, created[i].Name); err != nil {
+			log.Printf("huawei: rollback failed to delete %s %q: %v", created[i].Kind, created[i].Name, err)
+		}
+	}
+}
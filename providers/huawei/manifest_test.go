@@ -0,0 +1,171 @@
+package huawei
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testManifest = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+data:
+  foo: bar
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: my-pod
+spec:
+  containers:
+  - name: app
+    image: busybox
+`
+
+func TestApplyManifestCreatesEachObject(t *testing.T) {
+	var created []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/test-project/configmaps/my-config", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/configmaps", func(w http.ResponseWriter, r *http.Request) {
+		created = append(created, "ConfigMap/my-config")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods/my-pod", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods", func(w http.ResponseWriter, r *http.Request) {
+		created = append(created, "Pod/my-pod")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	results, err := p.ApplyManifest(context.Background(), strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatalf("ApplyManifest() error = %v", err)
+	}
+	if got, want := len(results), 2; got != want {
+		t.Fatalf("len(results) = %d, want %d", got, want)
+	}
+	for _, r := range results {
+		if r.Action != "created" {
+			t.Errorf("result %s/%s action = %q, want %q", r.Kind, r.Name, r.Action, "created")
+		}
+	}
+	if got, want := fmt.Sprint(created), fmt.Sprint([]string{"ConfigMap/my-config", "Pod/my-pod"}); got != want {
+		t.Errorf("created resources = %v, want %v", created, []string{"ConfigMap/my-config", "Pod/my-pod"})
+	}
+}
+
+const testPodManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: my-pod
+  namespace: default
+spec:
+  containers:
+  - name: app
+    image: busybox
+`
+
+// TestApplyManifestPodRoundTripsRealNamespace drives a Pod through
+// ApplyManifest -> stampPodAnnotations -> listPods -> GetPod with a real namespace
+// different from p.project, the same regression setPodAnnotations has for CreatePod:
+// the virtual-kubelet-namespace annotation must capture the pod's real namespace, not
+// the already-rewritten p.project, or GetPod can never find it again.
+func TestApplyManifestPodRoundTripsRealNamespace(t *testing.T) {
+	const realNamespace = "default"
+
+	var stored []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods/my-pod", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, stored)
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	if _, err := p.ApplyManifest(context.Background(), strings.NewReader(testPodManifest)); err != nil {
+		t.Fatalf("ApplyManifest() error = %v", err)
+	}
+
+	if err := p.listPods(); err != nil {
+		t.Fatalf("listPods() error = %v", err)
+	}
+
+	got, err := p.GetPod(realNamespace, "my-pod")
+	if err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetPod() = nil, want the pod cached under its real namespace")
+	}
+	if got.Namespace != realNamespace {
+		t.Errorf("GetPod().Namespace = %q, want %q", got.Namespace, realNamespace)
+	}
+}
+
+func TestApplyManifestRollsBackOnFailure(t *testing.T) {
+	var deleted []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/test-project/configmaps/my-config", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, "ConfigMap/my-config")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/configmaps", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods/my-pod", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("boom"))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	_, err := p.ApplyManifest(context.Background(), strings.NewReader(testManifest))
+	if err == nil {
+		t.Fatal("ApplyManifest() error = nil, want non-nil")
+	}
+	if got, want := deleted, []string{"ConfigMap/my-config"}; len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("deleted = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+package huawei
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// defaultResyncInterval is how often the watcher ends its watch and falls back to a full
+// list, even when the watch connection is healthy.
+const defaultResyncInterval = 30 * time.Second
+
+// podCache is an in-memory store of the pods known to be running under the provider's
+// project, keyed by "namespace/name", kept up to date by the watch loop started from Run.
+// It plays the same role as client-go's cache.Store in an informer. Callers must pass
+// pods with their real (non-CCI) namespace already restored and the internal
+// virtual-kubelet-namespace annotation already stripped, i.e. already run through
+// CCIProvider.deletePodAnnotations - see listPods/watchPods below.
+type podCache struct {
+	mu              sync.RWMutex
+	pods            map[string]*v1.Pod
+	resourceVersion string
+}
+
+func newPodCache() *podCache {
+	return &podCache{
+		pods: make(map[string]*v1.Pod),
+	}
+}
+
+func podCacheKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (c *podCache) get(namespace, name string) (*v1.Pod, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pod, ok := c.pods[podCacheKey(namespace, name)]
+	return pod, ok
+}
+
+func (c *podCache) list() []*v1.Pod {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pods := make([]*v1.Pod, 0, len(c.pods))
+	for _, pod := range c.pods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func (c *podCache) replace(pods []*v1.Pod, resourceVersion string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pods = make(map[string]*v1.Pod, len(pods))
+	for _, pod := range pods {
+		c.pods[podCacheKey(pod.Namespace, pod.Name)] = pod
+	}
+	c.resourceVersion = resourceVersion
+}
+
+func (c *podCache) put(pod *v1.Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pods[podCacheKey(pod.Namespace, pod.Name)] = pod
+}
+
+func (c *podCache) delete(pod *v1.Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pods, podCacheKey(pod.Namespace, pod.Name))
+}
+
+func (c *podCache) setResourceVersion(resourceVersion string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resourceVersion = resourceVersion
+}
+
+func (c *podCache) getResourceVersion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resourceVersion
+}
+
+// Run starts the informer loop that keeps the provider's pod cache in sync with CCI. It
+// blocks until ctx is cancelled, re-listing and re-watching with backoff whenever the
+// watch connection is lost, and re-listing on a plain schedule every resyncInterval even
+// if the watch connection never drops.
+func (p *CCIProvider) Run(ctx context.Context) error {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		if err := p.listPods(); err != nil {
+			log.Printf("huawei: failed to list pods, will retry: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff, maxBackoff)
+			continue
+		}
+
+		err := p.watchPods(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("huawei: pod watch closed at resourceVersion=%s, will re-list: %v", p.podCache.getResourceVersion(), err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff, maxBackoff)
+			continue
+		}
+
+		// watchPods only returned nil because it hit a clean resync or ctx was
+		// cancelled (already handled above) - either way the watch itself was healthy,
+		// so the backoff that guards against a persistently failing watch resets here.
+		backoff = time.Second
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// isResyncTimeout reports whether watchCtx was cut short by its own resync deadline
+// rather than by the parent ctx being cancelled, i.e. whether the watch ended because
+// resyncInterval elapsed and it's time for Run to do a plain full re-list.
+func isResyncTimeout(ctx, watchCtx context.Context) bool {
+	return watchCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+}
+
+func nextBackoff(cur, max time.Duration) time.Duration {
+	next := cur * 2
+	if next > max {
+		return max
+	}
+	return next
+}
+
+// listPods performs a full list of the project's pods and replaces the cache contents,
+// recording the resourceVersion the watch should resume from.
+func (p *CCIProvider) listPods() error {
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods"
+	r, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return fmt.Errorf("create list pods request failed: %v", err)
+	}
+
+	var list v1.PodList
+	if err := p.do(r, &list); err != nil {
+		return err
+	}
+
+	pods := make([]*v1.Pod, 0, len(list.Items))
+	for i := range list.Items {
+		p.deletePodAnnotations(&list.Items[i])
+		pods = append(pods, &list.Items[i])
+	}
+	p.podCache.replace(pods, list.ResourceVersion)
+	return nil
+}
+
+// watchPods opens a watch connection at the last known resourceVersion and applies each
+// event to the pod cache until the stream ends, ctx is cancelled, or resyncInterval
+// elapses. The resyncInterval bound mimics a real informer's periodic resync: it forces
+// watchPods to return every so often even over an otherwise healthy connection, so Run
+// re-lists from CCI instead of trusting a single watch to never miss an event.
+func (p *CCIProvider) watchPods(ctx context.Context) error {
+	watchCtx := ctx
+	if p.resyncInterval > 0 {
+		var cancel context.CancelFunc
+		watchCtx, cancel = context.WithTimeout(ctx, p.resyncInterval)
+		defer cancel()
+	}
+
+	uri := p.apiEndpoint + "/api/v1/namespaces/" + p.project + "/pods"
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("parse watch pods url failed: %v", err)
+	}
+	q := u.Query()
+	q.Set("watch", "true")
+	q.Set("resourceVersion", p.podCache.getResourceVersion())
+	u.RawQuery = q.Encode()
+
+	r, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("create watch pods request failed: %v", err)
+	}
+	r = r.WithContext(watchCtx)
+	if err = p.signRequest(r); err != nil {
+		return fmt.Errorf("sign the watch pods request failed: %v", err)
+	}
+
+	resp, err := p.client.HTTPClient.Do(r)
+	if err != nil {
+		if isResyncTimeout(ctx, watchCtx) {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("watch pods failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event metav1.WatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if isResyncTimeout(ctx, watchCtx) {
+				return nil
+			}
+			return err
+		}
+
+		var pod v1.Pod
+		if err := json.Unmarshal(event.Object.Raw, &pod); err != nil {
+			log.Printf("huawei: failed to decode watch event object: %v", err)
+			continue
+		}
+
+		resourceVersion := pod.ResourceVersion
+		switch watch.EventType(event.Type) {
+		case watch.Added, watch.Modified:
+			p.deletePodAnnotations(&pod)
+			p.podCache.put(&pod)
+			p.podCache.setResourceVersion(resourceVersion)
+		case watch.Deleted:
+			p.deletePodAnnotations(&pod)
+			p.podCache.delete(&pod)
+			p.podCache.setResourceVersion(resourceVersion)
+		case watch.Bookmark:
+			p.podCache.setResourceVersion(pod.ResourceVersion)
+		case watch.Error:
+			return fmt.Errorf("watch error event: %s", string(event.Object.Raw))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package huawei
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podInCache builds a pod the way podCache expects to receive it: already run through
+// deletePodAnnotations, i.e. namespace restored onto pod.Namespace and the internal
+// annotation gone.
+func podInCache(namespace, name, resourceVersion string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			ResourceVersion: resourceVersion,
+		},
+	}
+}
+
+func TestPodCacheReplaceAndGet(t *testing.T) {
+	c := newPodCache()
+	c.replace([]*v1.Pod{podInCache("default", "a", "1")}, "1")
+
+	pod, ok := c.get("default", "a")
+	if !ok {
+		t.Fatal("get() ok = false, want true")
+	}
+	if pod.Name != "a" {
+		t.Errorf("pod.Name = %q, want %q", pod.Name, "a")
+	}
+	if got, want := c.getResourceVersion(), "1"; got != want {
+		t.Errorf("resourceVersion = %q, want %q", got, want)
+	}
+}
+
+func TestPodCachePutAndDelete(t *testing.T) {
+	c := newPodCache()
+	c.put(podInCache("default", "a", "1"))
+
+	if _, ok := c.get("default", "a"); !ok {
+		t.Fatal("expected pod a to be present after put")
+	}
+
+	c.delete(podInCache("default", "a", "2"))
+	if _, ok := c.get("default", "a"); ok {
+		t.Fatal("expected pod a to be absent after delete")
+	}
+}
+
+func TestPodCacheList(t *testing.T) {
+	c := newPodCache()
+	c.replace([]*v1.Pod{
+		podInCache("default", "a", "1"),
+		podInCache("default", "b", "1"),
+	}, "1")
+
+	if got, want := len(c.list()), 2; got != want {
+		t.Errorf("list() len = %d, want %d", got, want)
+	}
+}
+
+// TestListPodsRestoresNamespace makes sure the pods listPods feeds into the cache have
+// their real k8s namespace restored and the internal virtual-kubelet-namespace
+// annotation stripped, the same way the old per-request GetPod used to before chunk0-2
+// introduced the watch cache.
+func TestListPodsRestoresNamespace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.Write([]byte(`{
+			"metadata": {"resourceVersion": "7"},
+			"items": [{
+				"metadata": {
+					"name": "mypod",
+					"namespace": "test-project",
+					"annotations": {"virtual-kubelet-namespace": "real-namespace"}
+				}
+			}]
+		}`))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	if err := p.listPods(); err != nil {
+		t.Fatalf("listPods() error = %v", err)
+	}
+
+	pod, ok := p.podCache.get("real-namespace", "mypod")
+	if !ok {
+		t.Fatal("expected pod to be cached under its real namespace")
+	}
+	if pod.Namespace != "real-namespace" {
+		t.Errorf("pod.Namespace = %q, want %q", pod.Namespace, "real-namespace")
+	}
+	if _, ok := pod.Annotations[podAnnotationNamespaceKey]; ok {
+		t.Error("expected internal namespace annotation to be stripped")
+	}
+}
+
+// TestWatchPodsResyncsOnInterval makes sure watchPods returns on its own once
+// resyncInterval elapses, even though the connection itself never errors - this is what
+// lets Run fall back to a plain full re-list on a schedule instead of only on reconnect.
+func TestWatchPodsResyncsOnInterval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		// Hold the connection open without sending any event, as a long-lived CCI watch
+		// would between pod changes.
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	p.resyncInterval = 20 * time.Millisecond
+
+	start := time.Now()
+	if err := p.watchPods(context.Background()); err != nil {
+		t.Fatalf("watchPods() error = %v, want nil on resync timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("watchPods() took %v, want it to return at roughly resyncInterval", elapsed)
+	}
+}
+
+// TestRunBacksOffOnPersistentWatchFailure makes sure Run grows its retry interval across
+// repeated watch failures instead of hot-looping at ~1s: listPods keeps succeeding (as it
+// would against a server whose watch upgrade is rejected by a misbehaving proxy), so the
+// backoff reset must depend on watchPods itself succeeding, not merely on listPods.
+func TestRunBacksOffOnPersistentWatchFailure(t *testing.T) {
+	var mu sync.Mutex
+	var watchAttempts []time.Time
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/test-project/pods", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			mu.Lock()
+			watchAttempts = append(watchAttempts, time.Now())
+			mu.Unlock()
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("watch rejected"))
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.Write([]byte(`{"metadata": {"resourceVersion": "1"}, "items": []}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := newTestProvider(t, srv)
+	p.resyncInterval = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.Run(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(watchAttempts) < 3 {
+		t.Fatalf("got %d watch attempts, want at least 3 to observe backoff growth", len(watchAttempts))
+	}
+	firstGap := watchAttempts[1].Sub(watchAttempts[0])
+	secondGap := watchAttempts[2].Sub(watchAttempts[1])
+	if secondGap <= firstGap {
+		t.Errorf("retry interval did not grow: first gap = %v, second gap = %v", firstGap, secondGap)
+	}
+}